@@ -5,9 +5,10 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/sirupsen/logrus"
+	"github.com/go-logr/logr"
 	"github.com/yedf/dtm/common"
 )
 
@@ -44,30 +45,43 @@ func TransInfoFromReq(c *gin.Context) *TransInfo {
 type BarrierModel struct {
 	common.ModelBase
 	TransInfo
+	Reason string
+	Result string
 }
 
-func logExec(tx *sql.Tx, query string, args ...interface{}) (sql.Result, error) {
-	logrus.Printf("executing: "+query, args...)
-	return tx.Exec(query, args...)
+func logExecCtx(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	res, err := tx.ExecContext(ctx, query, args...)
+	log := loggerFrom(ctx)
+	if err != nil {
+		log.Error(err, "barrier exec failed", "duration_ms", time.Since(start).Milliseconds())
+	} else {
+		log.V(1).Info("barrier exec", "duration_ms", time.Since(start).Milliseconds())
+	}
+	return res, err
 }
 
-func logQueryRow(tx *sql.Tx, query string, args ...interface{}) *sql.Row {
-	logrus.Printf("querying: "+query, args...)
-	return tx.QueryRow(query, args...)
+func logQueryRowCtx(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := tx.QueryRowContext(ctx, query, args...)
+	loggerFrom(ctx).V(1).Info("barrier query", "duration_ms", time.Since(start).Milliseconds())
+	return row
 }
 
-// TableName gorm table name
-func (BarrierModel) TableName() string { return "dtm_barrier.barrier" }
+// TableName gorm table name, following SetBarrierTable overrides
+func (BarrierModel) TableName() string { return barrierTable }
 
-func insertBarrier(tx *sql.Tx, transType string, gid string, branchID string, branchType string, reason string) (int64, error) {
+func insertBarrierCtx(ctx context.Context, tx *sql.Tx, dialect Dialect, table string, transType string, gid string, branchID string, branchType string, reason string) (int64, error) {
 	if branchType == "" {
 		return 0, nil
 	}
-	res, err := logExec(tx, "insert ignore into dtm_barrier.barrier(trans_type, gid, branch_id, branch_type, reason) values(?,?,?,?,?)", transType, gid, branchID, branchType, reason)
+	res, err := logExecCtx(ctx, tx, dialect.insertIgnoreSQL(table), transType, gid, branchID, branchType, reason)
 	if err != nil {
 		return 0, err
 	}
-	return res.RowsAffected()
+	affected, err := res.RowsAffected()
+	loggerFrom(ctx).V(1).Info("barrier insert", "reason", reason, "rows_affected", affected)
+	return affected, err
 }
 
 // ThroughBarrierCall 子事务屏障，详细介绍见 https://zhuanlan.zhihu.com/p/388444465
@@ -80,37 +94,45 @@ func insertBarrier(tx *sql.Tx, transType string, gid string, branchID string, br
 // 如果发生悬挂，则busiCall不会被调用，直接返回错误 {"dtm_result": "FAILURE"}
 // 如果发生空补偿，则busiCall不会被调用，直接返回 {"dtm_result": "SUCCESS"}
 func ThroughBarrierCall(db *sql.DB, transInfo *TransInfo, busiCall BusiFunc) (res interface{}, rerr error) {
-	tx, rerr := db.BeginTx(context.Background(), &sql.TxOptions{})
-	if rerr != nil {
-		return
-	}
-	defer func() {
-		logrus.Printf("result is %v error is %v", res, rerr)
-		if x := recover(); x != nil {
-			tx.Rollback()
-			panic(x)
-		} else if rerr != nil {
-			tx.Rollback()
-		} else {
-			tx.Commit()
-		}
-	}()
+	return ThroughBarrierCallCtx(context.Background(), db, transInfo, func(ctx context.Context, db *sql.DB) (interface{}, error) {
+		return busiCall(db)
+	})
+}
+
+// ThroughBarrierCallWithDialect is like ThroughBarrierCall but uses an explicit Dialect
+// instead of guessing one from db's driver, for engines dialectForDB can't recognize
+func ThroughBarrierCallWithDialect(db *sql.DB, dialect Dialect, transInfo *TransInfo, busiCall BusiFunc) (res interface{}, rerr error) {
+	return ThroughBarrierCallCtxWithDialect(context.Background(), db, dialect, transInfo, func(ctx context.Context, db *sql.DB) (interface{}, error) {
+		return busiCall(db)
+	})
+}
+
+// throughBarrierCall 屏障逻辑的核心实现，被 ThroughBarrierCall 和 ThroughBarrierCallCtx 共用，
+// 所有数据库操作都带上 ctx，以便取消和超时能够传播下去，且 SQL 语句通过 dialect 生成以支持多种数据库。
+// 返回的 outcome 只反映业务逻辑本身的结果，调用方应该在提交/回滚事务之后，带上最终的 rerr 一起上报给
+// Observer，这样悬挂/空补偿等指标才能反映事务真正落盘的结果，而不是提交之前的中间状态
+func throughBarrierCall(ctx context.Context, tx *sql.Tx, db *sql.DB, dialect Dialect, table string, transInfo *TransInfo, busiCall BusiFuncCtx) (res interface{}, outcome Outcome, rerr error) {
 	ti := transInfo
+	log := loggerFrom(ctx).WithValues("trans_type", ti.TransType, "gid", ti.Gid, "branch_id", ti.BranchID, "branch_type", ti.BranchType)
+	ctx = logr.NewContext(ctx, log)
+	outcome = OutcomeNormal
 	originType := map[string]string{
 		"cancel":     "try",
 		"compensate": "action",
 	}[ti.BranchType]
-	originAffected, _ := insertBarrier(tx, ti.TransType, ti.Gid, ti.BranchID, originType, ti.BranchType)
-	currentAffected, rerr := insertBarrier(tx, ti.TransType, ti.Gid, ti.BranchID, ti.BranchType, ti.BranchType)
-	logrus.Printf("originAffected: %d currentAffected: %d", originAffected, currentAffected)
+	originAffected, _ := insertBarrierCtx(ctx, tx, dialect, table, ti.TransType, ti.Gid, ti.BranchID, originType, ti.BranchType)
+	currentAffected, rerr := insertBarrierCtx(ctx, tx, dialect, table, ti.TransType, ti.Gid, ti.BranchID, ti.BranchType, ti.BranchType)
+	log.V(1).Info("barrier inserted", "origin_affected", originAffected, "current_affected", currentAffected)
 	if (ti.BranchType == "cancel" || ti.BranchType == "compensate") && originAffected > 0 { // 这个是空补偿，返回成功
+		outcome = OutcomeNullCompensation
 		res = common.MS{"dtm_result": "SUCCESS"}
 		return
 	} else if currentAffected == 0 { // 插入不成功
 		var result sql.NullString
-		err := logQueryRow(tx, "select result from dtm_barrier.barrier where trans_type=? and gid=? and branch_id=? and branch_type=? and reason=?",
+		err := logQueryRowCtx(ctx, tx, dialect.selectResultSQL(table),
 			ti.TransType, ti.Gid, ti.BranchID, ti.BranchType, ti.BranchType).Scan(&result)
 		if err == sql.ErrNoRows { // 这个是悬挂操作，返回失败，AP收到这个返回，会尽快回滚
+			outcome = OutcomeHanging
 			res = common.MS{"dtm_result": "FAILURE"}
 			return
 		}
@@ -119,17 +141,19 @@ func ThroughBarrierCall(db *sql.DB, transInfo *TransInfo, busiCall BusiFunc) (re
 			return
 		}
 		if result.Valid { // 数据库里有上一次结果，返回上一次的结果
+			outcome = OutcomeIdempotentReplay
 			res = json.Unmarshal([]byte(result.String), &res)
 			return
 		}
 		// 数据库里没有上次的结果，属于重复空补偿，直接返回成功
+		outcome = OutcomeNullCompensation
 		res = common.MS{"dtm_result": "SUCCESS"}
 		return
 	}
-	res, rerr = busiCall(db)
+	res, rerr = busiCall(ctx, db)
 	if rerr == nil { // 正确返回了，需要将结果保存到数据库
 		sval := common.MustMarshalString(res)
-		_, rerr = logExec(tx, "update dtm_barrier.barrier set result=? where trans_type=? and gid=? and branch_id=? and branch_type=?", sval,
+		_, rerr = logExecCtx(ctx, tx, dialect.updateResultSQL(table), sval,
 			ti.TransType, ti.Gid, ti.BranchID, ti.BranchType)
 	}
 	return
@@ -0,0 +1,104 @@
+package dtmcli
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// barrierTable is the schema-qualified barrier table name used by ThroughBarrierCall.
+// Override it with SetBarrierTable when the table does not live at dtm_barrier.barrier,
+// e.g. postgres deployments that keep it in public or a custom schema
+var barrierTable = "dtm_barrier.barrier"
+
+// SetBarrierTable overrides the schema/table name used for the barrier table
+func SetBarrierTable(table string) {
+	barrierTable = table
+}
+
+// Dialect carries the SQL syntax differences ThroughBarrierCall needs to support
+// more than one database engine. mysql, postgres and sqlite are built in; other
+// engines can build their own Dialect with NewDialect and pass it to
+// ThroughBarrierCallWithDialect / ThroughBarrierCallCtxWithDialect. The zero Dialect{}
+// is not a valid dialect; use NewDialect or one of the DialectMySQL/DialectPostgres/
+// DialectSQLite values instead
+type Dialect struct {
+	name        string
+	placeholder func(n int) string
+}
+
+// NewDialect builds a custom Dialect for an engine that isn't built in. placeholder
+// returns the SQL bind-variable placeholder for the nth (1-based) argument - e.g.
+// func(int) string { return "?" } for positional placeholders, or
+// func(n int) string { return fmt.Sprintf("$%d", n) } for numbered ones. A nil
+// placeholder defaults to "?"
+func NewDialect(name string, placeholder func(n int) string) Dialect {
+	if placeholder == nil {
+		placeholder = func(int) string { return "?" }
+	}
+	return Dialect{name: name, placeholder: placeholder}
+}
+
+// DialectMySQL is the default dialect, matching the historical hardcoded behavior
+var DialectMySQL = NewDialect("mysql", func(n int) string { return "?" })
+
+// DialectPostgres uses INSERT ... ON CONFLICT DO NOTHING and $N placeholders
+var DialectPostgres = NewDialect("postgres", func(n int) string { return fmt.Sprintf("$%d", n) })
+
+// DialectSQLite uses INSERT OR IGNORE and ? placeholders
+var DialectSQLite = NewDialect("sqlite", func(n int) string { return "?" })
+
+// ph returns the placeholder for the nth bind argument. It tolerates the zero
+// Dialect{} (nil placeholder) by falling back to "?" rather than panicking
+func (d Dialect) ph(n int) string {
+	if d.placeholder == nil {
+		return "?"
+	}
+	return d.placeholder(n)
+}
+
+func (d Dialect) insertIgnoreSQL(table string) string {
+	switch d.name {
+	case "postgres":
+		return fmt.Sprintf("insert into %s(trans_type, gid, branch_id, branch_type, reason) values(%s,%s,%s,%s,%s) on conflict do nothing",
+			table, d.ph(1), d.ph(2), d.ph(3), d.ph(4), d.ph(5))
+	case "sqlite":
+		return fmt.Sprintf("insert or ignore into %s(trans_type, gid, branch_id, branch_type, reason) values(%s,%s,%s,%s,%s)",
+			table, d.ph(1), d.ph(2), d.ph(3), d.ph(4), d.ph(5))
+	default: // mysql
+		return fmt.Sprintf("insert ignore into %s(trans_type, gid, branch_id, branch_type, reason) values(%s,%s,%s,%s,%s)",
+			table, d.ph(1), d.ph(2), d.ph(3), d.ph(4), d.ph(5))
+	}
+}
+
+func (d Dialect) selectResultSQL(table string) string {
+	return fmt.Sprintf("select result from %s where trans_type=%s and gid=%s and branch_id=%s and branch_type=%s and reason=%s",
+		table, d.ph(1), d.ph(2), d.ph(3), d.ph(4), d.ph(5))
+}
+
+func (d Dialect) updateResultSQL(table string) string {
+	return fmt.Sprintf("update %s set result=%s where trans_type=%s and gid=%s and branch_id=%s and branch_type=%s",
+		table, d.ph(1), d.ph(2), d.ph(3), d.ph(4), d.ph(5))
+}
+
+// dialectForDB guesses a Dialect from the driver behind db. Drivers that don't match
+// a known name fall back to DialectMySQL, preserving the historical behavior. Postgres
+// users on the pgx stdlib driver (jackc/pgx/*/stdlib, the standard way to use pgx
+// through database/sql) are matched on "stdlib.Driver" since %T on that driver never
+// contains "pgx" itself; if a driver can't be recognized this way, call
+// ThroughBarrierCallWithDialect / ThroughBarrierCallCtxWithDialect with an explicit
+// Dialect instead of relying on detection
+func dialectForDB(db *sql.DB) Dialect {
+	return dialectForDriverType(fmt.Sprintf("%T", db.Driver()))
+}
+
+func dialectForDriverType(driverType string) Dialect {
+	switch {
+	case strings.Contains(driverType, "pq.") || strings.Contains(driverType, "pgx") || strings.Contains(driverType, "stdlib.Driver"):
+		return DialectPostgres
+	case strings.Contains(driverType, "sqlite"):
+		return DialectSQLite
+	default:
+		return DialectMySQL
+	}
+}
@@ -0,0 +1,75 @@
+package dtmcli
+
+import "testing"
+
+func TestZeroDialectDoesNotPanic(t *testing.T) {
+	var d Dialect
+	if got := d.ph(1); got != "?" {
+		t.Errorf("Dialect{}.ph(1) = %q, want %q", got, "?")
+	}
+	if got := d.insertIgnoreSQL("t"); got == "" {
+		t.Error("Dialect{}.insertIgnoreSQL returned empty string")
+	}
+}
+
+func TestDialectSQLGeneration(t *testing.T) {
+	const table = "dtm_barrier.barrier"
+	cases := []struct {
+		name             string
+		dialect          Dialect
+		wantInsertIgnore string
+		wantSelectResult string
+		wantUpdateResult string
+	}{
+		{
+			name:             "mysql",
+			dialect:          DialectMySQL,
+			wantInsertIgnore: "insert ignore into dtm_barrier.barrier(trans_type, gid, branch_id, branch_type, reason) values(?,?,?,?,?)",
+			wantSelectResult: "select result from dtm_barrier.barrier where trans_type=? and gid=? and branch_id=? and branch_type=? and reason=?",
+			wantUpdateResult: "update dtm_barrier.barrier set result=? where trans_type=? and gid=? and branch_id=? and branch_type=?",
+		},
+		{
+			name:             "postgres",
+			dialect:          DialectPostgres,
+			wantInsertIgnore: "insert into dtm_barrier.barrier(trans_type, gid, branch_id, branch_type, reason) values($1,$2,$3,$4,$5) on conflict do nothing",
+			wantSelectResult: "select result from dtm_barrier.barrier where trans_type=$1 and gid=$2 and branch_id=$3 and branch_type=$4 and reason=$5",
+			wantUpdateResult: "update dtm_barrier.barrier set result=$1 where trans_type=$2 and gid=$3 and branch_id=$4 and branch_type=$5",
+		},
+		{
+			name:             "sqlite",
+			dialect:          DialectSQLite,
+			wantInsertIgnore: "insert or ignore into dtm_barrier.barrier(trans_type, gid, branch_id, branch_type, reason) values(?,?,?,?,?)",
+			wantSelectResult: "select result from dtm_barrier.barrier where trans_type=? and gid=? and branch_id=? and branch_type=? and reason=?",
+			wantUpdateResult: "update dtm_barrier.barrier set result=? where trans_type=? and gid=? and branch_id=? and branch_type=?",
+		},
+	}
+	for _, c := range cases {
+		if got := c.dialect.insertIgnoreSQL(table); got != c.wantInsertIgnore {
+			t.Errorf("%s insertIgnoreSQL = %q, want %q", c.name, got, c.wantInsertIgnore)
+		}
+		if got := c.dialect.selectResultSQL(table); got != c.wantSelectResult {
+			t.Errorf("%s selectResultSQL = %q, want %q", c.name, got, c.wantSelectResult)
+		}
+		if got := c.dialect.updateResultSQL(table); got != c.wantUpdateResult {
+			t.Errorf("%s updateResultSQL = %q, want %q", c.name, got, c.wantUpdateResult)
+		}
+	}
+}
+
+func TestDialectForDriverType(t *testing.T) {
+	cases := []struct {
+		driverType string
+		want       Dialect
+	}{
+		{"*mysql.MySQLDriver", DialectMySQL},
+		{"*pq.Driver", DialectPostgres},
+		{"*stdlib.Driver", DialectPostgres}, // jackc/pgx/*/stdlib registered via database/sql
+		{"*sqlite3.SQLiteDriver", DialectSQLite},
+		{"*unknowndriver.Driver", DialectMySQL},
+	}
+	for _, c := range cases {
+		if got := dialectForDriverType(c.driverType); got.name != c.want.name {
+			t.Errorf("dialectForDriverType(%q) = %q, want %q", c.driverType, got.name, c.want.name)
+		}
+	}
+}
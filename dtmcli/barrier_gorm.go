@@ -0,0 +1,85 @@
+package dtmcli
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/yedf/dtm/common"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BusiFuncGorm busi func that operates through a *gorm.DB, used by ThroughBarrierCallGorm
+type BusiFuncGorm func(tx *gorm.DB) (interface{}, error)
+
+// ThroughBarrierCallGorm 子事务屏障的 GORM 版本，使用 BarrierModel 通过 GORM 完成屏障记录的
+// 插入/查询/更新，方便已经用 GORM 管理业务写入的服务，将业务写入和屏障记录放到同一个事务里提交。
+// tx 需要是调用方已经开启的事务（例如 db.Transaction 回调里拿到的 tx），本函数不负责提交或回滚，
+// 调用方应把本函数返回的 rerr 作为 db.Transaction 回调的返回值，交由 GORM 统一处理。
+// 日志和 Observer 上报都走 tx.Statement.Context，和 throughBarrierCall 共用同一套 logr/Observer 插件，
+// 调用方应在拿到 tx 前用 db.WithContext(ctx) 挂上希望使用的 logger
+func ThroughBarrierCallGorm(tx *gorm.DB, transInfo *TransInfo, busiCall BusiFuncGorm) (res interface{}, rerr error) {
+	ti := transInfo
+	ctx := tx.Statement.Context
+	log := loggerFrom(ctx).WithValues("trans_type", ti.TransType, "gid", ti.Gid, "branch_id", ti.BranchID, "branch_type", ti.BranchType)
+	start := time.Now()
+	outcome := OutcomeNormal
+	defer func() {
+		log.V(1).Info("barrier call finished", "result", res, "error", rerr)
+		barrierObserver.ObserveBarrier(ctx, ti, outcome, time.Since(start), rerr)
+	}()
+	originType := map[string]string{
+		"cancel":     "try",
+		"compensate": "action",
+	}[ti.BranchType]
+	originAffected := insertBarrierGorm(tx, ti.TransType, ti.Gid, ti.BranchID, originType, ti.BranchType)
+	currentAffected := insertBarrierGorm(tx, ti.TransType, ti.Gid, ti.BranchID, ti.BranchType, ti.BranchType)
+	log.V(1).Info("barrier inserted", "origin_affected", originAffected, "current_affected", currentAffected)
+	if (ti.BranchType == "cancel" || ti.BranchType == "compensate") && originAffected > 0 { // 这个是空补偿，返回成功
+		outcome = OutcomeNullCompensation
+		res = common.MS{"dtm_result": "SUCCESS"}
+		return
+	} else if currentAffected == 0 { // 插入不成功
+		barrier := BarrierModel{}
+		err := tx.Where("trans_type=? and gid=? and branch_id=? and branch_type=? and reason=?",
+			ti.TransType, ti.Gid, ti.BranchID, ti.BranchType, ti.BranchType).First(&barrier).Error
+		if err == gorm.ErrRecordNotFound { // 这个是悬挂操作，返回失败，AP收到这个返回，会尽快回滚
+			outcome = OutcomeHanging
+			res = common.MS{"dtm_result": "FAILURE"}
+			return
+		}
+		if err != nil {
+			rerr = err
+			return
+		}
+		if barrier.Result != "" { // 数据库里有上一次结果，返回上一次的结果
+			outcome = OutcomeIdempotentReplay
+			rerr = json.Unmarshal([]byte(barrier.Result), &res)
+			return
+		}
+		// 数据库里没有上次的结果，属于重复空补偿，直接返回成功
+		outcome = OutcomeNullCompensation
+		res = common.MS{"dtm_result": "SUCCESS"}
+		return
+	}
+	res, rerr = busiCall(tx)
+	if rerr == nil { // 正确返回了，需要将结果保存到数据库
+		sval := common.MustMarshalString(res)
+		rerr = tx.Model(&BarrierModel{}).
+			Where("trans_type=? and gid=? and branch_id=? and branch_type=?", ti.TransType, ti.Gid, ti.BranchID, ti.BranchType).
+			Updates(map[string]interface{}{"result": sval}).Error
+	}
+	return
+}
+
+func insertBarrierGorm(tx *gorm.DB, transType string, gid string, branchID string, branchType string, reason string) int64 {
+	if branchType == "" {
+		return 0
+	}
+	model := BarrierModel{
+		TransInfo: TransInfo{TransType: transType, Gid: gid, BranchID: branchID, BranchType: branchType},
+		Reason:    reason,
+	}
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model)
+	return result.RowsAffected
+}
@@ -0,0 +1,76 @@
+package dtmcli
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// TransInfoFromGRPCMetadata 从 grpc 的 metadata 中构造事务信息，供基于 go-zero 或原生
+// grpc-go 搭建的服务使用子事务屏障，而不必依赖 gin
+func TransInfoFromGRPCMetadata(ctx context.Context) (*TransInfo, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("no metadata found in grpc context")
+	}
+	get := func(key string) string {
+		if vals := md.Get(key); len(vals) > 0 {
+			return vals[0]
+		}
+		return ""
+	}
+	ti := &TransInfo{
+		TransType:  get("trans_type"),
+		Gid:        get("gid"),
+		BranchID:   get("branch_id"),
+		BranchType: get("branch_type"),
+	}
+	if ti.TransType == "" || ti.Gid == "" || ti.BranchID == "" || ti.BranchType == "" {
+		return nil, fmt.Errorf("invlid trans info: %v", ti)
+	}
+	return ti, nil
+}
+
+// BusiFuncCtx busi func that accepts a context, used by ThroughBarrierCallCtx so
+// that cancellation and deadlines propagate down into the business call
+type BusiFuncCtx func(ctx context.Context, db *sql.DB) (interface{}, error)
+
+// ThroughBarrierCallCtx 子事务屏障的 context 版本，将 ctx 传入 BeginTx 及屏障相关的查询，
+// 使调用方的取消和超时能够传播到数据库操作中。其余语义与 ThroughBarrierCall 一致
+func ThroughBarrierCallCtx(ctx context.Context, db *sql.DB, transInfo *TransInfo, busiCall BusiFuncCtx) (res interface{}, rerr error) {
+	return ThroughBarrierCallCtxWithDialect(ctx, db, dialectForDB(db), transInfo, busiCall)
+}
+
+// ThroughBarrierCallCtxWithDialect is like ThroughBarrierCallCtx but uses an explicit
+// Dialect instead of guessing one from db's driver
+func ThroughBarrierCallCtxWithDialect(ctx context.Context, db *sql.DB, dialect Dialect, transInfo *TransInfo, busiCall BusiFuncCtx) (res interface{}, rerr error) {
+	tx, rerr := db.BeginTx(ctx, &sql.TxOptions{})
+	if rerr != nil {
+		return
+	}
+	log := loggerFrom(ctx).WithValues("trans_type", transInfo.TransType, "gid", transInfo.Gid, "branch_id", transInfo.BranchID, "branch_type", transInfo.BranchType)
+	start := time.Now()
+	var outcome Outcome
+	// runs after the commit/rollback defer below has resolved, so the logged/observed
+	// outcome reflects whether the transaction is actually durable, not just whether
+	// the business logic inside it succeeded
+	defer func() {
+		log.V(1).Info("barrier call finished", "result", res, "error", rerr)
+		barrierObserver.ObserveBarrier(ctx, transInfo, outcome, time.Since(start), rerr)
+	}()
+	defer func() {
+		if x := recover(); x != nil {
+			tx.Rollback()
+			panic(x)
+		} else if rerr != nil {
+			tx.Rollback()
+		} else if cerr := tx.Commit(); cerr != nil {
+			rerr = cerr
+		}
+	}()
+	res, outcome, rerr = throughBarrierCall(ctx, tx, db, dialect, barrierTable, transInfo, busiCall)
+	return
+}
@@ -0,0 +1,40 @@
+package dtmcli
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func TestTransInfoFromGRPCMetadata(t *testing.T) {
+	md := metadata.Pairs(
+		"trans_type", "saga",
+		"gid", "gid1",
+		"branch_id", "01",
+		"branch_type", "action",
+	)
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+	ti, err := TransInfoFromGRPCMetadata(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := TransInfo{TransType: "saga", Gid: "gid1", BranchID: "01", BranchType: "action"}
+	if *ti != want {
+		t.Errorf("TransInfoFromGRPCMetadata = %+v, want %+v", *ti, want)
+	}
+}
+
+func TestTransInfoFromGRPCMetadataNoMetadata(t *testing.T) {
+	if _, err := TransInfoFromGRPCMetadata(context.Background()); err == nil {
+		t.Error("expected error when ctx carries no metadata, got nil")
+	}
+}
+
+func TestTransInfoFromGRPCMetadataMissingField(t *testing.T) {
+	md := metadata.Pairs("trans_type", "saga", "gid", "gid1", "branch_id", "01")
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+	if _, err := TransInfoFromGRPCMetadata(ctx); err == nil {
+		t.Error("expected error when branch_type is missing, got nil")
+	}
+}
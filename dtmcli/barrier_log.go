@@ -0,0 +1,30 @@
+package dtmcli
+
+import (
+	"context"
+
+	"github.com/bombsimon/logrusr/v4"
+	"github.com/go-logr/logr"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultLogger is used whenever ctx carries no logr.Logger of its own. It wraps the
+// package's historical logrus output so existing deployments keep seeing barrier logs
+// without configuring anything
+var defaultLogger logr.Logger = logrusr.New(logrus.StandardLogger())
+
+// SetLogger overrides the logger ThroughBarrierCall and friends fall back to when ctx
+// carries none of its own
+func SetLogger(l logr.Logger) {
+	defaultLogger = l
+}
+
+// loggerFrom returns the logr.Logger attached to ctx (see ThroughBarrierCallCtx, which
+// enriches it with gid/branch_id/branch_type via logr.NewContext), falling back to the
+// logger set with SetLogger
+func loggerFrom(ctx context.Context) logr.Logger {
+	if l, err := logr.FromContext(ctx); err == nil {
+		return l
+	}
+	return defaultLogger
+}
@@ -0,0 +1,97 @@
+package dtmcli
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Outcome classifies the four observable results of ThroughBarrierCall
+type Outcome string
+
+const (
+	// OutcomeNormal is a regular call: busiCall ran and its result was saved
+	OutcomeNormal Outcome = "normal"
+	// OutcomeIdempotentReplay means busiCall did not run; a previously saved result was returned
+	OutcomeIdempotentReplay Outcome = "idempotent_replay"
+	// OutcomeNullCompensation means a cancel/compensate arrived before its try/action; {"dtm_result":"SUCCESS"} was returned without running busiCall
+	OutcomeNullCompensation Outcome = "null_compensation"
+	// OutcomeHanging means a try/action arrived after its cancel/compensate had already inserted the barrier row; {"dtm_result":"FAILURE"} was returned without running busiCall
+	OutcomeHanging Outcome = "hanging"
+)
+
+// Observer is notified of every ThroughBarrierCall outcome. Operators running DTM at
+// scale need this to alert on rising hanging/null-compensation rates rather than
+// grepping logs. SetObserver installs one; the default is a no-op
+type Observer interface {
+	ObserveBarrier(ctx context.Context, ti *TransInfo, outcome Outcome, duration time.Duration, err error)
+}
+
+var barrierObserver Observer = noopObserver{}
+
+// SetObserver installs the Observer that ThroughBarrierCall and its variants report
+// outcomes to
+func SetObserver(o Observer) {
+	if o == nil {
+		o = noopObserver{}
+	}
+	barrierObserver = o
+}
+
+type noopObserver struct{}
+
+func (noopObserver) ObserveBarrier(context.Context, *TransInfo, Outcome, time.Duration, error) {}
+
+// OTelObserver is an Observer backed by OpenTelemetry: it emits a span per call with
+// trans_type/gid/branch_id/branch_type/outcome attributes, and records
+// dtm_barrier_calls_total{outcome=...} and dtm_barrier_duration_seconds
+type OTelObserver struct {
+	tracer   trace.Tracer
+	calls    metric.Int64Counter
+	duration metric.Float64Histogram
+}
+
+// NewOTelObserver builds an OTelObserver on top of the globally configured
+// OpenTelemetry tracer/meter providers
+func NewOTelObserver() (*OTelObserver, error) {
+	meter := otel.Meter("github.com/yedf/dtm/dtmcli")
+	calls, err := meter.Int64Counter("dtm_barrier_calls_total")
+	if err != nil {
+		return nil, err
+	}
+	duration, err := meter.Float64Histogram("dtm_barrier_duration_seconds")
+	if err != nil {
+		return nil, err
+	}
+	return &OTelObserver{
+		tracer:   otel.Tracer("github.com/yedf/dtm/dtmcli"),
+		calls:    calls,
+		duration: duration,
+	}, nil
+}
+
+func (o *OTelObserver) ObserveBarrier(ctx context.Context, ti *TransInfo, outcome Outcome, duration time.Duration, err error) {
+	attrs := []attribute.KeyValue{
+		attribute.String("trans_type", ti.TransType),
+		attribute.String("gid", ti.Gid),
+		attribute.String("branch_id", ti.BranchID),
+		attribute.String("branch_type", ti.BranchType),
+		attribute.String("outcome", string(outcome)),
+	}
+	// ObserveBarrier only runs after the barrier transaction has already been committed
+	// or rolled back, so the span is built here with explicit start/end timestamps
+	// back-dated by duration rather than spanning real wall-clock time
+	end := time.Now()
+	_, span := o.tracer.Start(ctx, "dtm.barrier", trace.WithTimestamp(end.Add(-duration)))
+	span.SetAttributes(attrs...)
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End(trace.WithTimestamp(end))
+	o.calls.Add(ctx, 1, metric.WithAttributes(attrs...))
+	o.duration.Record(ctx, duration.Seconds(), metric.WithAttributes(attrs...))
+}
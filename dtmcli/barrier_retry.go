@@ -0,0 +1,115 @@
+package dtmcli
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// RetryOptions controls the backoff behavior of ThroughBarrierCallRetry
+type RetryOptions struct {
+	// MaxAttempts is the total number of attempts, including the first one. Defaults to 3
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. Defaults to 50ms
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between retries. Defaults to 2s
+	MaxBackoff time.Duration
+	// Multiplier scales the backoff after each failed attempt. Defaults to 2
+	Multiplier float64
+	// RetryableErr additionally classifies an error as retryable, on top of the
+	// built-in deadlock / lock-wait-timeout / serialization-failure detection
+	RetryableErr func(error) bool
+	// Timeout bounds a single attempt, applied as a deadline on the ctx passed
+	// down to BeginTx and the barrier queries. Zero means no deadline
+	Timeout time.Duration
+}
+
+func (o RetryOptions) withDefaults() RetryOptions {
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = 3
+	}
+	if o.InitialBackoff <= 0 {
+		o.InitialBackoff = 50 * time.Millisecond
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = 2 * time.Second
+	}
+	if o.Multiplier <= 0 {
+		o.Multiplier = 2
+	}
+	return o
+}
+
+// sqlState is satisfied by postgres driver errors (lib/pq, pgx) that expose their
+// SQLSTATE code, used to detect 40001 serialization failures without a hard dependency
+type sqlState interface {
+	SQLState() string
+}
+
+// isRetryableErr classifies mysql deadlocks (1213), mysql lock wait timeouts (1205)
+// and postgres serialization failures (40001) as retryable
+func isRetryableErr(err error) bool {
+	if err == nil {
+		return false
+	}
+	var merr *mysql.MySQLError
+	if errors.As(err, &merr) {
+		return merr.Number == 1213 || merr.Number == 1205
+	}
+	var state sqlState
+	if errors.As(err, &state) {
+		return state.SQLState() == "40001"
+	}
+	return false
+}
+
+// ThroughBarrierCallRetry wraps ThroughBarrierCall in a retry loop with exponential
+// backoff and jitter, similar to the RunInNewTxn pattern used elsewhere for transient
+// DB contention: on a retryable error (deadlock, lock wait timeout, serialization
+// failure, or opts.RetryableErr) it rolls back and retries; a successful call, and any
+// idempotent replay of a previous result, is returned as-is.
+// ctx bounds the whole retry loop, so a caller's cancellation or deadline (e.g. an
+// incoming gRPC context) stops retries immediately instead of racing pointless DB work;
+// opts.Timeout additionally bounds each individual attempt
+func ThroughBarrierCallRetry(ctx context.Context, db *sql.DB, transInfo *TransInfo, busiCall BusiFuncCtx, opts RetryOptions) (res interface{}, rerr error) {
+	opts = opts.withDefaults()
+	backoff := opts.InitialBackoff
+	for attempt := 1; attempt <= opts.MaxAttempts; attempt++ {
+		attemptCtx := ctx
+		var cancel context.CancelFunc
+		if opts.Timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		}
+		res, rerr = ThroughBarrierCallCtx(attemptCtx, db, transInfo, busiCall)
+		if cancel != nil {
+			cancel()
+		}
+		if rerr == nil || attempt == opts.MaxAttempts {
+			return
+		}
+		if ctx.Err() != nil { // caller gave up; stop retrying instead of sleeping and trying again
+			rerr = ctx.Err()
+			return
+		}
+		if !isRetryableErr(rerr) && (opts.RetryableErr == nil || !opts.RetryableErr(rerr)) {
+			return
+		}
+		sleep := time.Duration(float64(backoff) * (0.5 + rand.Float64())) // nolint:gosec
+		if sleep > opts.MaxBackoff {
+			sleep = opts.MaxBackoff
+		}
+		select {
+		case <-ctx.Done():
+			rerr = ctx.Err()
+			return
+		case <-time.After(sleep):
+		}
+		backoff = time.Duration(math.Min(float64(opts.MaxBackoff), float64(backoff)*opts.Multiplier))
+	}
+	return
+}
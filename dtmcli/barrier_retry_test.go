@@ -0,0 +1,36 @@
+package dtmcli
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+type fakeSQLStateErr struct{ state string }
+
+func (e fakeSQLStateErr) Error() string    { return fmt.Sprintf("sqlstate %s", e.state) }
+func (e fakeSQLStateErr) SQLState() string { return e.state }
+
+func TestIsRetryableErr(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"mysql deadlock", &mysql.MySQLError{Number: 1213}, true},
+		{"mysql lock wait timeout", &mysql.MySQLError{Number: 1205}, true},
+		{"mysql other error", &mysql.MySQLError{Number: 1062}, false},
+		{"postgres serialization failure", fakeSQLStateErr{"40001"}, true},
+		{"postgres other sqlstate", fakeSQLStateErr{"23505"}, false},
+		{"wrapped mysql deadlock", fmt.Errorf("query failed: %w", &mysql.MySQLError{Number: 1213}), true},
+		{"plain error", errors.New("boom"), false},
+	}
+	for _, c := range cases {
+		if got := isRetryableErr(c.err); got != c.want {
+			t.Errorf("isRetryableErr(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}